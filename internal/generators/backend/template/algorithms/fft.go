@@ -22,6 +22,45 @@ const fftParallelThreshold = 64
 
 var numCpus = uint(runtime.NumCPU())
 
+// stridedView is an offset, strided view over a backing []fr.Element, used by difFFT/ditFFT to express
+// the sub-problems of the FFT recursion without reslicing the array at every level. half splits a view
+// into its lower and upper halves, which share the backing array and stride, sparing the butterfly stage
+// a rewrite and leaving room for stride-2 (four-step/six-step) FFT variants to be built on top later.
+type stridedView struct {
+	data   []fr.Element
+	offset int
+	stride int
+	length int
+}
+
+func newStridedView(a []fr.Element) stridedView {
+	return stridedView{data: a, stride: 1, length: len(a)}
+}
+
+// at returns a pointer to the i-th element of the view.
+func (v stridedView) at(i int) *fr.Element {
+	return &v.data[v.offset+i*v.stride]
+}
+
+// half returns the lower ([0:m)) and upper ([m:2m)) halves of v as stridedViews.
+func (v stridedView) half() (lo, hi stridedView) {
+	m := v.length >> 1
+	lo = stridedView{data: v.data, offset: v.offset, stride: v.stride, length: m}
+	hi = stridedView{data: v.data, offset: v.offset + m*v.stride, stride: v.stride, length: m}
+	return
+}
+
+// quarter returns the four quarters ([0:m), [m:2m), [2m:3m), [3m:4m)) of v as stridedViews, used by the
+// radix-4 FFT butterfly.
+func (v stridedView) quarter() (q0, q1, q2, q3 stridedView) {
+	m := v.length >> 2
+	q0 = stridedView{data: v.data, offset: v.offset, stride: v.stride, length: m}
+	q1 = stridedView{data: v.data, offset: v.offset + m*v.stride, stride: v.stride, length: m}
+	q2 = stridedView{data: v.data, offset: v.offset + 2*m*v.stride, stride: v.stride, length: m}
+	q3 = stridedView{data: v.data, offset: v.offset + 3*m*v.stride, stride: v.stride, length: m}
+	return
+}
+
 // FFT computes (recursively) the discrete Fourier transform of a and stores the result in a.
 // if fType == DIT (decimation in time), the input must be in bit-reversed order
 // if fType == DIF (decimation in frequency), the output will be in bit-reversed order
@@ -30,31 +69,40 @@ func FFT(a []fr.Element, w fr.Element, fType FFTType) {
 	switch fType {
 	case DIF:
 		var wg sync.WaitGroup
-		difFFT(a, w, 1, nil)
+		difFFT(newStridedView(a), w, 1, nil)
 		wg.Wait()
 	case DIT:
-		ditFFT(a, w, 1, nil)
+		ditFFT(newStridedView(a), w, 1, nil)
 	default:
 		panic("not implemented")
 	}
 }
 
-func difFFT(a []fr.Element, w fr.Element, splits uint, chDone chan struct{}) {
+func difFFT(a stridedView, w fr.Element, splits uint, chDone chan struct{}) {
 	if chDone != nil {
 		defer func() {
 			chDone <- struct{}{}
 		}()
 	}
-	n := len(a)
+	n := a.length
 	if n == 1 {
 		return
 	}
-	m := n >> 1
+
+	// radix-4 cuts the number of twiddle multiplications by ~25% over two radix-2 stages; fall back to
+	// the radix-2 butterfly below for n == 2, which has no radix-4 decomposition.
+	if n%4 == 0 && n > 2 {
+		difFFTRadix4(a, w, splits)
+		return
+	}
+
+	lo, hi := a.half()
+	m := lo.length
 
 	// i == 0
-	t := a[0]
-	a[0].Add(&a[0], &a[m])
-	a[m].Sub(&t, &a[m])
+	t := *lo.at(0)
+	lo.at(0).Add(lo.at(0), hi.at(0))
+	hi.at(0).Sub(&t, hi.at(0))
 
 	// if m == 1, then next iteration ends, no need to call 2 extra functions for that
 	if m == 1 {
@@ -65,12 +113,12 @@ func difFFT(a []fr.Element, w fr.Element, splits uint, chDone chan struct{}) {
 	wPow := w
 
 	for i := 1; i < m; i++ {
-		t = a[i]
-		a[i].Add(&a[i], &a[i+m])
+		t = *lo.at(i)
+		lo.at(i).Add(lo.at(i), hi.at(i))
 
-		a[i+m].
-			Sub(&t, &a[i+m]).
-			Mul(&a[i+m], &wPow)
+		hi.at(i).
+			Sub(&t, hi.at(i)).
+			Mul(hi.at(i), &wPow)
 
 		wPow.Mul(&wPow, &w)
 	}
@@ -81,72 +129,876 @@ func difFFT(a []fr.Element, w fr.Element, splits uint, chDone chan struct{}) {
 	serial := (splits<<1) > numCpus || m <= fftParallelThreshold
 
 	if serial {
-		difFFT(a[0:m], w, splits,nil)
-		difFFT(a[m:n], w, splits,nil)
+		difFFT(lo, w, splits, nil)
+		difFFT(hi, w, splits, nil)
 	} else {
 		splits <<= 1
 		chDone := make(chan struct{}, 1)
-		go difFFT(a[m:n], w, splits,chDone)
-		difFFT(a[0:m], w, splits,nil)
+		go difFFT(hi, w, splits, chDone)
+		difFFT(lo, w, splits, nil)
 		<-chDone
 	}
 
 }
 
 
-func ditFFT(a []fr.Element, w fr.Element, splits uint, chDone chan struct{})  {
+func ditFFT(a stridedView, w fr.Element, splits uint, chDone chan struct{})  {
 	if chDone != nil {
 		defer func() {
 			chDone <- struct{}{}
 		}()
 	}
-	n := len(a)
+	n := a.length
 	if n == 1 {
 		return
 	}
-	m := n >> 1
+
+	if n%4 == 0 && n > 2 {
+		ditFFTRadix4(a, w, splits)
+		return
+	}
+
+	lo, hi := a.half()
+	m := lo.length
 	var wSquare fr.Element
 	wSquare.Square(&w)
 
 	serial := (splits<<1) > numCpus || m <= fftParallelThreshold
 
 	if serial {
-		ditFFT(a[0:m], wSquare,  splits, nil) // even
-		ditFFT(a[m:], wSquare,  splits, nil)  // odds
+		ditFFT(lo, wSquare,  splits, nil) // even
+		ditFFT(hi, wSquare,  splits, nil)  // odds
 	} else {
 		splits <<= 1
 		chDone := make(chan struct{}, 1)
-		go ditFFT(a[m:n], wSquare,  splits, chDone)
-		ditFFT(a[0:m], wSquare, splits, nil)
+		go ditFFT(hi, wSquare,  splits, chDone)
+		ditFFT(lo, wSquare, splits, nil)
 		<-chDone
 	}
 	var tm fr.Element
 
 	// k == 0
 	// wPow == 1
-	t := a[0]
-	a[0].Add(&a[0], &a[m])
-	a[m].Sub(&t, &a[m])
+	t := *lo.at(0)
+	lo.at(0).Add(lo.at(0), hi.at(0))
+	hi.at(0).Sub(&t, hi.at(0))
 
 	if m == 1 {
 		return
 	}
 
-	// k == 1 
+	// k == 1
 	// wPow == w
-	t = a[1]
-	tm.Mul(&a[1+m], &w)
-	a[1].Add(&a[1], &tm)
-	a[1+m].Sub(&t, &tm)
-	
+	t = *lo.at(1)
+	tm.Mul(hi.at(1), &w)
+	lo.at(1).Add(lo.at(1), &tm)
+	hi.at(1).Sub(&t, &tm)
+
 	// k > 2
 	wPow := wSquare
 	for k := 2; k < m; k++ {
+		t = *lo.at(k)
+		tm.Mul(hi.at(k), &wPow)
+		lo.at(k).Add(lo.at(k), &tm)
+
+		hi.at(k).Sub(&t, &tm)
+
+		wPow.Mul(&wPow, &w)
+	}
+}
+
+// difFFTRadix4 applies a single radix-4 DIF butterfly stage to a (len(a) a multiple of 4, len(a) > 2)
+// and recurses into the four resulting quarters. Given the quad (a,b,c,d) = a[i], a[i+m], a[i+2m],
+// a[i+3m] (m = len(a)/4) and twiddles (1,w,w^2,w^3), it computes t0=a+c, t1=a-c, t2=b+d, t3=(b-d)*I
+// (I = w^(len(a)/4), the fourth root of unity), then writes back t0+t2, (t0-t2)*w^2, (t1+t3)*w,
+// (t1-t3)*w^3 -- 3 twiddle multiplications per quad (plus the one by I) instead of the 2 per pair that
+// two radix-2 stages need.
+// Quarters 1 and 2 carry w^2 and w^1 respectively (not w^1 and w^2): that's the base-4 digit reversal
+// the quarter recursion below relies on to match the base-2 bit-reversed order of the rest of the module.
+func difFFTRadix4(a stridedView, w fr.Element, splits uint) {
+	n := a.length
+	m := n >> 2
+
+	var bQuarter big.Int
+	bQuarter.SetInt64(int64(n / 4))
+	var I fr.Element
+	I.Exp(w, &bQuarter)
+
+	var t0, t1, t2, t3 fr.Element
+
+	// i == 0: wPow == wPow2 == wPow3 == 1
+	{
+		pa, pb, pc, pd := a.at(0), a.at(m), a.at(2*m), a.at(3*m)
+		t0.Add(pa, pc)
+		t1.Sub(pa, pc)
+		t2.Add(pb, pd)
+		t3.Sub(pb, pd)
+		t3.Mul(&t3, &I)
+
+		pa.Add(&t0, &t2)
+		pb.Sub(&t0, &t2)
+		pc.Add(&t1, &t3)
+		pd.Sub(&t1, &t3)
+	}
+
+	if m > 1 {
+		wPow := w
+		for i := 1; i < m; i++ {
+			var wPow2, wPow3 fr.Element
+			wPow2.Square(&wPow)
+			wPow3.Mul(&wPow2, &wPow)
+
+			pa, pb, pc, pd := a.at(i), a.at(i+m), a.at(i+2*m), a.at(i+3*m)
+			t0.Add(pa, pc)
+			t1.Sub(pa, pc)
+			t2.Add(pb, pd)
+			t3.Sub(pb, pd)
+			t3.Mul(&t3, &I)
+
+			// quarters 1 and 2 are swapped relative to the natural (t0-t2, t1+t3) frequency order:
+			// the base-4 digit reversal that the recursion below relies on lands frequency bin 1 in
+			// quarter 2 and frequency bin 2 in quarter 1.
+			pa.Add(&t0, &t2)
+			pb.Sub(&t0, &t2).Mul(pb, &wPow2)
+			pc.Add(&t1, &t3).Mul(pc, &wPow)
+			pd.Sub(&t1, &t3).Mul(pd, &wPow3)
+
+			wPow.Mul(&wPow, &w)
+		}
+	}
+
+	// note: w is passed by value; raise it to the root needed by the (4x smaller) quarters
+	w.Square(&w)
+	w.Square(&w)
+
+	if m == 1 {
+		return
+	}
+
+	q0, q1, q2, q3 := a.quarter()
+	serial := (splits<<2) > numCpus || m <= fftParallelThreshold
+
+	if serial {
+		difFFT(q0, w, splits, nil)
+		difFFT(q1, w, splits, nil)
+		difFFT(q2, w, splits, nil)
+		difFFT(q3, w, splits, nil)
+	} else {
+		splits <<= 2
+		chDone := make(chan struct{}, 3)
+		go difFFT(q1, w, splits, chDone)
+		go difFFT(q2, w, splits, chDone)
+		go difFFT(q3, w, splits, chDone)
+		difFFT(q0, w, splits, nil)
+		<-chDone
+		<-chDone
+		<-chDone
+	}
+}
+
+// ditFFTRadix4 is the DIT counterpart of difFFTRadix4: it first recurses into the four quarters of a
+// (with the root w^4 they are transformed under), then combines them with a single radix-4 butterfly
+// stage instead of two radix-2 ones.
+func ditFFTRadix4(a stridedView, w fr.Element, splits uint) {
+	n := a.length
+	m := n >> 2
+
+	var wQuarter fr.Element
+	wQuarter.Square(&w)
+	wQuarter.Square(&wQuarter)
+
+	q0, q1, q2, q3 := a.quarter()
+	serial := (splits<<2) > numCpus || m <= fftParallelThreshold
+
+	if serial {
+		ditFFT(q0, wQuarter, splits, nil)
+		ditFFT(q1, wQuarter, splits, nil)
+		ditFFT(q2, wQuarter, splits, nil)
+		ditFFT(q3, wQuarter, splits, nil)
+	} else {
+		splits <<= 2
+		chDone := make(chan struct{}, 3)
+		go ditFFT(q1, wQuarter, splits, chDone)
+		go ditFFT(q2, wQuarter, splits, chDone)
+		go ditFFT(q3, wQuarter, splits, chDone)
+		ditFFT(q0, wQuarter, splits, nil)
+		<-chDone
+		<-chDone
+		<-chDone
+	}
+
+	var bQuarter big.Int
+	bQuarter.SetInt64(int64(n / 4))
+	var I fr.Element
+	I.Exp(w, &bQuarter)
+
+	var t0, t1, t2, t3, b, c, d fr.Element
+
+	// k == 0: wPow == wPow2 == wPow3 == 1
+	{
+		pa, pb, pc, pd := a.at(0), a.at(m), a.at(2*m), a.at(3*m)
+		t0.Add(pa, pb)
+		t1.Sub(pa, pb)
+		t2.Add(pc, pd)
+		t3.Sub(pc, pd)
+		t3.Mul(&t3, &I)
+
+		pa.Add(&t0, &t2)
+		pb.Add(&t1, &t3)
+		pc.Sub(&t0, &t2)
+		pd.Sub(&t1, &t3)
+	}
+
+	if m == 1 {
+		return
+	}
+
+	wPow := w
+	for k := 1; k < m; k++ {
+		var wPow2, wPow3 fr.Element
+		wPow2.Square(&wPow)
+		wPow3.Mul(&wPow2, &wPow)
+
+		// twiddle quarters 1, 2 and 3 before combining (the mirror of difFFTRadix4, which twiddles
+		// after combining); quarters 1 and 2 use the same swapped powers as difFFTRadix4.
+		pa, pb, pc, pd := a.at(k), a.at(k+m), a.at(k+2*m), a.at(k+3*m)
+		b.Mul(pb, &wPow2)
+		c.Mul(pc, &wPow)
+		d.Mul(pd, &wPow3)
+
+		t0.Add(pa, &b)
+		t1.Sub(pa, &b)
+		t2.Add(&c, &d)
+		t3.Sub(&c, &d)
+		t3.Mul(&t3, &I)
+
+		pa.Add(&t0, &t2)
+		pb.Add(&t1, &t3)
+		pc.Sub(&t0, &t2)
+		pd.Sub(&t1, &t3)
+
+		wPow.Mul(&wPow, &w)
+	}
+}
+
+// FFT computes (recursively) the discrete Fourier transform of a with respect to domain.Generator and
+// stores the result in a, using domain.Twiddles instead of recomputing the twiddle factors on the fly.
+// if fType == DIT (decimation in time), the input must be in bit-reversed order
+// if fType == DIF (decimation in frequency), the output will be in bit-reversed order
+// len(a) must equal domain.Cardinality.
+func (domain *Domain) FFT(a []fr.Element, fType FFTType) {
+	switch fType {
+	case DIF:
+		var wg sync.WaitGroup
+		difFFTTable(newStridedView(a), domain.Twiddles, 0, 1, nil)
+		wg.Wait()
+	case DIT:
+		ditFFTTable(newStridedView(a), domain.Twiddles, 0, 1, nil)
+	default:
+		panic("not implemented")
+	}
+}
+
+// FFTInverse computes (recursively) the inverse discrete Fourier transform of a and stores the result in a.
+// if fType == DIT (decimation in time), the input must be in bit-reversed order
+// if fType == DIF (decimation in frequency), the output will be in bit-reversed order
+// len(a) must equal domain.Cardinality.
+func (domain *Domain) FFTInverse(a []fr.Element, fType FFTType) {
+	switch fType {
+	case DIF:
+		var wg sync.WaitGroup
+		difFFTTable(newStridedView(a), domain.TwiddlesInv, 0, 1, nil)
+		wg.Wait()
+	case DIT:
+		ditFFTTable(newStridedView(a), domain.TwiddlesInv, 0, 1, nil)
+	default:
+		panic("not implemented")
+	}
+
+	// scale by CardinalityInv
+	for i := 0; i < len(a); i++ {
+		a[i].Mul(&a[i], &domain.CardinalityInv)
+	}
+}
+
+// CosetFFT computes (recursively) the discrete Fourier transform of a on the coset shifted domain and stores the result in a.
+// the coefficient of index i is first scaled in place by domain.CosetTwiddles[i] (for DIT, whose input is
+// in bit-reversed order, a is brought to natural order for the scaling and back), then the regular FFT is applied.
+func (domain *Domain) CosetFFT(a []fr.Element, fType FFTType) {
+	if fType == DIT {
+		BitReverse(a)
+	}
+	scaleCoset(a, domain.CosetTwiddles)
+	if fType == DIT {
+		BitReverse(a)
+	}
+	domain.FFT(a, fType)
+}
+
+// CosetFFTInverse computes (recursively) the inverse discrete Fourier transform of a on the coset shifted domain and stores the result in a.
+// the regular inverse FFT is applied first, then the coefficient of index i is scaled in place by
+// domain.CosetTwiddlesInv[i] (for DIF, whose output is in bit-reversed order, a is brought to natural
+// order for the scaling and back).
+func (domain *Domain) CosetFFTInverse(a []fr.Element, fType FFTType) {
+	domain.FFTInverse(a, fType)
+	if fType == DIF {
+		BitReverse(a)
+	}
+	scaleCoset(a, domain.CosetTwiddlesInv)
+	if fType == DIF {
+		BitReverse(a)
+	}
+}
+
+// scaleCoset multiplies a[i] by coset[i] in place, shifting a to (or back from) the coset domain.
+func scaleCoset(a []fr.Element, coset []fr.Element) {
+	for i := 0; i < len(a); i++ {
+		a[i].Mul(&a[i], &coset[i])
+	}
+}
+
+// difFFTTable is the DIF butterfly of difFFT, but reads its twiddle factors from twiddles[level]
+// instead of updating wPow multiplicatively at every iteration of the inner loop.
+func difFFTTable(a stridedView, twiddles [][]fr.Element, level int, splits uint, chDone chan struct{}) {
+	if chDone != nil {
+		defer func() {
+			chDone <- struct{}{}
+		}()
+	}
+	n := a.length
+	if n == 1 {
+		return
+	}
+
+	// dispatch to the radix-4 table butterfly so that domain.FFT's output matches the free FFT's for
+	// the same n (both prefer radix-4 whenever n is a multiple of 4): see difFFTRadix4.
+	if n%4 == 0 && n > 2 {
+		difFFTTableRadix4(a, twiddles, level, splits)
+		return
+	}
+
+	lo, hi := a.half()
+	m := lo.length
+
+	// i == 0
+	t := *lo.at(0)
+	lo.at(0).Add(lo.at(0), hi.at(0))
+	hi.at(0).Sub(&t, hi.at(0))
+
+	if m == 1 {
+		return
+	}
+
+	tw := twiddles[level]
+	for i := 1; i < m; i++ {
+		t = *lo.at(i)
+		lo.at(i).Add(lo.at(i), hi.at(i))
+
+		hi.at(i).
+			Sub(&t, hi.at(i)).
+			Mul(hi.at(i), &tw[i])
+	}
+
+	serial := (splits<<1) > numCpus || m <= fftParallelThreshold
+
+	if serial {
+		difFFTTable(lo, twiddles, level+1, splits, nil)
+		difFFTTable(hi, twiddles, level+1, splits, nil)
+	} else {
+		splits <<= 1
+		chDone := make(chan struct{}, 1)
+		go difFFTTable(hi, twiddles, level+1, splits, chDone)
+		difFFTTable(lo, twiddles, level+1, splits, nil)
+		<-chDone
+	}
+}
+
+// difFFTTableRadix4 is the table-driven counterpart of difFFTRadix4: wPow is read from twiddles[level][i]
+// instead of being updated multiplicatively, but wPow2 and wPow3 are still derived from it locally since
+// twiddles[level] only holds n/2 entries, one quarter short of what a table lookup for w^3i would need.
+func difFFTTableRadix4(a stridedView, twiddles [][]fr.Element, level int, splits uint) {
+	n := a.length
+	m := n >> 2
+
+	tw := twiddles[level]
+
+	var bQuarter big.Int
+	bQuarter.SetInt64(int64(n / 4))
+	var I fr.Element
+	I.Exp(tw[1], &bQuarter)
+
+	var t0, t1, t2, t3 fr.Element
+
+	// i == 0: wPow == wPow2 == wPow3 == 1
+	{
+		pa, pb, pc, pd := a.at(0), a.at(m), a.at(2*m), a.at(3*m)
+		t0.Add(pa, pc)
+		t1.Sub(pa, pc)
+		t2.Add(pb, pd)
+		t3.Sub(pb, pd)
+		t3.Mul(&t3, &I)
+
+		pa.Add(&t0, &t2)
+		pb.Sub(&t0, &t2)
+		pc.Add(&t1, &t3)
+		pd.Sub(&t1, &t3)
+	}
+
+	for i := 1; i < m; i++ {
+		wPow := tw[i]
+		var wPow2, wPow3 fr.Element
+		wPow2.Square(&wPow)
+		wPow3.Mul(&wPow2, &wPow)
+
+		pa, pb, pc, pd := a.at(i), a.at(i+m), a.at(i+2*m), a.at(i+3*m)
+		t0.Add(pa, pc)
+		t1.Sub(pa, pc)
+		t2.Add(pb, pd)
+		t3.Sub(pb, pd)
+		t3.Mul(&t3, &I)
+
+		pa.Add(&t0, &t2)
+		pb.Sub(&t0, &t2).Mul(pb, &wPow2)
+		pc.Add(&t1, &t3).Mul(pc, &wPow)
+		pd.Sub(&t1, &t3).Mul(pd, &wPow3)
+	}
+
+	if m == 1 {
+		return
+	}
+
+	q0, q1, q2, q3 := a.quarter()
+	serial := (splits<<2) > numCpus || m <= fftParallelThreshold
+
+	if serial {
+		difFFTTable(q0, twiddles, level+2, splits, nil)
+		difFFTTable(q1, twiddles, level+2, splits, nil)
+		difFFTTable(q2, twiddles, level+2, splits, nil)
+		difFFTTable(q3, twiddles, level+2, splits, nil)
+	} else {
+		splits <<= 2
+		chDone := make(chan struct{}, 3)
+		go difFFTTable(q1, twiddles, level+2, splits, chDone)
+		go difFFTTable(q2, twiddles, level+2, splits, chDone)
+		go difFFTTable(q3, twiddles, level+2, splits, chDone)
+		difFFTTable(q0, twiddles, level+2, splits, nil)
+		<-chDone
+		<-chDone
+		<-chDone
+	}
+}
+
+// ditFFTTable is the DIT butterfly of ditFFT, but reads its twiddle factors from twiddles[level]
+// instead of updating wPow multiplicatively at every iteration of the inner loop.
+func ditFFTTable(a stridedView, twiddles [][]fr.Element, level int, splits uint, chDone chan struct{}) {
+	if chDone != nil {
+		defer func() {
+			chDone <- struct{}{}
+		}()
+	}
+	n := a.length
+	if n == 1 {
+		return
+	}
+
+	if n%4 == 0 && n > 2 {
+		ditFFTTableRadix4(a, twiddles, level, splits)
+		return
+	}
+
+	lo, hi := a.half()
+	m := lo.length
+
+	serial := (splits<<1) > numCpus || m <= fftParallelThreshold
+
+	if serial {
+		ditFFTTable(lo, twiddles, level+1, splits, nil) // even
+		ditFFTTable(hi, twiddles, level+1, splits, nil) // odds
+	} else {
+		splits <<= 1
+		chDone := make(chan struct{}, 1)
+		go ditFFTTable(hi, twiddles, level+1, splits, chDone)
+		ditFFTTable(lo, twiddles, level+1, splits, nil)
+		<-chDone
+	}
+	var tm fr.Element
+
+	// k == 0
+	t := *lo.at(0)
+	lo.at(0).Add(lo.at(0), hi.at(0))
+	hi.at(0).Sub(&t, hi.at(0))
+
+	if m == 1 {
+		return
+	}
+
+	tw := twiddles[level]
+	for k := 1; k < m; k++ {
+		t = *lo.at(k)
+		tm.Mul(hi.at(k), &tw[k])
+		lo.at(k).Add(lo.at(k), &tm)
+
+		hi.at(k).Sub(&t, &tm)
+	}
+}
+
+// ditFFTTableRadix4 is the table-driven counterpart of ditFFTRadix4: wPow is read from twiddles[level][k]
+// instead of being updated multiplicatively, but wPow2 and wPow3 are still derived from it locally since
+// twiddles[level] only holds n/2 entries, one quarter short of what a table lookup for w^3k would need.
+func ditFFTTableRadix4(a stridedView, twiddles [][]fr.Element, level int, splits uint) {
+	n := a.length
+	m := n >> 2
+
+	q0, q1, q2, q3 := a.quarter()
+	serial := (splits<<2) > numCpus || m <= fftParallelThreshold
+
+	if serial {
+		ditFFTTable(q0, twiddles, level+2, splits, nil)
+		ditFFTTable(q1, twiddles, level+2, splits, nil)
+		ditFFTTable(q2, twiddles, level+2, splits, nil)
+		ditFFTTable(q3, twiddles, level+2, splits, nil)
+	} else {
+		splits <<= 2
+		chDone := make(chan struct{}, 3)
+		go ditFFTTable(q1, twiddles, level+2, splits, chDone)
+		go ditFFTTable(q2, twiddles, level+2, splits, chDone)
+		go ditFFTTable(q3, twiddles, level+2, splits, chDone)
+		ditFFTTable(q0, twiddles, level+2, splits, nil)
+		<-chDone
+		<-chDone
+		<-chDone
+	}
+
+	tw := twiddles[level]
+
+	var bQuarter big.Int
+	bQuarter.SetInt64(int64(n / 4))
+	var I fr.Element
+	I.Exp(tw[1], &bQuarter)
+
+	var t0, t1, t2, t3, b, c, d fr.Element
+
+	// k == 0: wPow == wPow2 == wPow3 == 1
+	{
+		pa, pb, pc, pd := a.at(0), a.at(m), a.at(2*m), a.at(3*m)
+		t0.Add(pa, pb)
+		t1.Sub(pa, pb)
+		t2.Add(pc, pd)
+		t3.Sub(pc, pd)
+		t3.Mul(&t3, &I)
+
+		pa.Add(&t0, &t2)
+		pb.Add(&t1, &t3)
+		pc.Sub(&t0, &t2)
+		pd.Sub(&t1, &t3)
+	}
+
+	for k := 1; k < m; k++ {
+		wPow := tw[k]
+		var wPow2, wPow3 fr.Element
+		wPow2.Square(&wPow)
+		wPow3.Mul(&wPow2, &wPow)
+
+		pa, pb, pc, pd := a.at(k), a.at(k+m), a.at(k+2*m), a.at(k+3*m)
+		b.Mul(pb, &wPow2)
+		c.Mul(pc, &wPow)
+		d.Mul(pd, &wPow3)
+
+		t0.Add(pa, &b)
+		t1.Sub(pa, &b)
+		t2.Add(&c, &d)
+		t3.Sub(&c, &d)
+		t3.Mul(&t3, &I)
+
+		pa.Add(&t0, &t2)
+		pb.Add(&t1, &t3)
+		pc.Sub(&t0, &t2)
+		pd.Sub(&t1, &t3)
+	}
+}
+
+// FFTG1 computes (recursively) the discrete Fourier transform of a, a vector of G1 points, and stores the result in a.
+// if fType == DIT (decimation in time), the input must be in bit-reversed order
+// if fType == DIF (decimation in frequency), the output will be in bit-reversed order
+// len(a) must be a power of 2, and w must be a len(a)th root of unity in field F.
+// This is typically used to convert a KZG SRS between the Lagrange and monomial bases.
+func FFTG1(a []curve.G1Affine, w fr.Element, fType FFTType) {
+	jac := make([]curve.G1Jac, len(a))
+	for i := 0; i < len(a); i++ {
+		jac[i].FromAffine(&a[i])
+	}
+
+	switch fType {
+	case DIF:
+		var wg sync.WaitGroup
+		difFFTG1(jac, w, 1, nil)
+		wg.Wait()
+	case DIT:
+		ditFFTG1(jac, w, 1, nil)
+	default:
+		panic("not implemented")
+	}
+
+	for i := 0; i < len(a); i++ {
+		a[i].FromJacobian(&jac[i])
+	}
+}
+
+// difFFTG1 is the DIF butterfly of difFFT, applied to a vector of G1 points in Jacobian form: the twiddle
+// multiplication is a scalar multiplication of the point by wPow instead of a field multiplication.
+//
+// Deviation from the request: it asked for a per-twiddle window table and/or endomorphism-accelerated
+// ScalarMultiplication, plus amortizing the wPow-to-big.Int conversion once per butterfly column.
+// Neither is implemented; this does a plain ToBigIntRegular and ScalarMultiplication per element, same
+// as before. A window table needs a fixed base, but wPow and a[i+m] both change every iteration, so
+// there's no fixed base here to build one against, and endomorphism acceleration needs curve-specific
+// constants not available in this template; amortizing ToBigIntRegular doesn't apply either since wPow
+// differs per element rather than being shared down a column. Left unaccelerated rather than attempted.
+func difFFTG1(a []curve.G1Jac, w fr.Element, splits uint, chDone chan struct{}) {
+	if chDone != nil {
+		defer func() {
+			chDone <- struct{}{}
+		}()
+	}
+	n := len(a)
+	if n == 1 {
+		return
+	}
+	m := n >> 1
+
+	// i == 0
+	t := a[0]
+	a[0].AddAssign(&a[m])
+	t.SubAssign(&a[m])
+	a[m].Set(&t)
+
+	if m == 1 {
+		return
+	}
+
+	// wPow == w^1
+	wPow := w
+	var wPowBigInt big.Int
+
+	for i := 1; i < m; i++ {
+		t = a[i]
+		a[i].AddAssign(&a[i+m])
+
+		t.SubAssign(&a[i+m])
+		wPow.ToBigIntRegular(&wPowBigInt)
+		a[i+m].ScalarMultiplication(&t, &wPowBigInt)
+
+		wPow.Mul(&wPow, &w)
+	}
+
+	// note: w is passed by value
+	w.Square(&w)
+
+	serial := (splits<<1) > numCpus || m <= fftParallelThreshold
+
+	if serial {
+		difFFTG1(a[0:m], w, splits, nil)
+		difFFTG1(a[m:n], w, splits, nil)
+	} else {
+		splits <<= 1
+		chDone := make(chan struct{}, 1)
+		go difFFTG1(a[m:n], w, splits, chDone)
+		difFFTG1(a[0:m], w, splits, nil)
+		<-chDone
+	}
+}
+
+// ditFFTG1 is the DIT counterpart of difFFTG1; see its doc comment for the deviation from the request
+// (window table / endomorphism acceleration, amortized ToBigIntRegular) left unimplemented here.
+func ditFFTG1(a []curve.G1Jac, w fr.Element, splits uint, chDone chan struct{}) {
+	if chDone != nil {
+		defer func() {
+			chDone <- struct{}{}
+		}()
+	}
+	n := len(a)
+	if n == 1 {
+		return
+	}
+	m := n >> 1
+	var wSquare fr.Element
+	wSquare.Square(&w)
+
+	serial := (splits<<1) > numCpus || m <= fftParallelThreshold
+
+	if serial {
+		ditFFTG1(a[0:m], wSquare, splits, nil) // even
+		ditFFTG1(a[m:], wSquare, splits, nil)  // odds
+	} else {
+		splits <<= 1
+		chDone := make(chan struct{}, 1)
+		go ditFFTG1(a[m:n], wSquare, splits, chDone)
+		ditFFTG1(a[0:m], wSquare, splits, nil)
+		<-chDone
+	}
+
+	var tm, t curve.G1Jac
+	var wPowBigInt big.Int
+
+	// k == 0
+	// wPow == 1
+	t = a[0]
+	a[0].AddAssign(&a[m])
+	t.SubAssign(&a[m])
+	a[m].Set(&t)
+
+	if m == 1 {
+		return
+	}
+
+	// k > 0
+	wPow := w
+	for k := 1; k < m; k++ {
+		t = a[k]
+		wPow.ToBigIntRegular(&wPowBigInt)
+		tm.ScalarMultiplication(&a[k+m], &wPowBigInt)
+
+		a[k].AddAssign(&tm)
+		a[k+m].Set(&t).SubAssign(&tm)
+
+		wPow.Mul(&wPow, &w)
+	}
+}
+
+// FFTG2 computes (recursively) the discrete Fourier transform of a, a vector of G2 points, and stores the result in a.
+// if fType == DIT (decimation in time), the input must be in bit-reversed order
+// if fType == DIF (decimation in frequency), the output will be in bit-reversed order
+// len(a) must be a power of 2, and w must be a len(a)th root of unity in field F.
+func FFTG2(a []curve.G2Affine, w fr.Element, fType FFTType) {
+	jac := make([]curve.G2Jac, len(a))
+	for i := 0; i < len(a); i++ {
+		jac[i].FromAffine(&a[i])
+	}
+
+	switch fType {
+	case DIF:
+		var wg sync.WaitGroup
+		difFFTG2(jac, w, 1, nil)
+		wg.Wait()
+	case DIT:
+		ditFFTG2(jac, w, 1, nil)
+	default:
+		panic("not implemented")
+	}
+
+	for i := 0; i < len(a); i++ {
+		a[i].FromJacobian(&jac[i])
+	}
+}
+
+// difFFTG2 is difFFTG1's G2 counterpart; see its doc comment for the deviation from the request left
+// unimplemented here.
+func difFFTG2(a []curve.G2Jac, w fr.Element, splits uint, chDone chan struct{}) {
+	if chDone != nil {
+		defer func() {
+			chDone <- struct{}{}
+		}()
+	}
+	n := len(a)
+	if n == 1 {
+		return
+	}
+	m := n >> 1
+
+	// i == 0
+	t := a[0]
+	a[0].AddAssign(&a[m])
+	t.SubAssign(&a[m])
+	a[m].Set(&t)
+
+	if m == 1 {
+		return
+	}
+
+	// wPow == w^1
+	wPow := w
+	var wPowBigInt big.Int
+
+	for i := 1; i < m; i++ {
+		t = a[i]
+		a[i].AddAssign(&a[i+m])
+
+		t.SubAssign(&a[i+m])
+		wPow.ToBigIntRegular(&wPowBigInt)
+		a[i+m].ScalarMultiplication(&t, &wPowBigInt)
+
+		wPow.Mul(&wPow, &w)
+	}
+
+	// note: w is passed by value
+	w.Square(&w)
+
+	serial := (splits<<1) > numCpus || m <= fftParallelThreshold
+
+	if serial {
+		difFFTG2(a[0:m], w, splits, nil)
+		difFFTG2(a[m:n], w, splits, nil)
+	} else {
+		splits <<= 1
+		chDone := make(chan struct{}, 1)
+		go difFFTG2(a[m:n], w, splits, chDone)
+		difFFTG2(a[0:m], w, splits, nil)
+		<-chDone
+	}
+}
+
+// ditFFTG2 is the DIT counterpart of difFFTG2; see difFFTG1's doc comment for the deviation from the
+// request left unimplemented here.
+func ditFFTG2(a []curve.G2Jac, w fr.Element, splits uint, chDone chan struct{}) {
+	if chDone != nil {
+		defer func() {
+			chDone <- struct{}{}
+		}()
+	}
+	n := len(a)
+	if n == 1 {
+		return
+	}
+	m := n >> 1
+	var wSquare fr.Element
+	wSquare.Square(&w)
+
+	serial := (splits<<1) > numCpus || m <= fftParallelThreshold
+
+	if serial {
+		ditFFTG2(a[0:m], wSquare, splits, nil) // even
+		ditFFTG2(a[m:], wSquare, splits, nil)  // odds
+	} else {
+		splits <<= 1
+		chDone := make(chan struct{}, 1)
+		go ditFFTG2(a[m:n], wSquare, splits, chDone)
+		ditFFTG2(a[0:m], wSquare, splits, nil)
+		<-chDone
+	}
+
+	var tm, t curve.G2Jac
+	var wPowBigInt big.Int
+
+	// k == 0
+	// wPow == 1
+	t = a[0]
+	a[0].AddAssign(&a[m])
+	t.SubAssign(&a[m])
+	a[m].Set(&t)
+
+	if m == 1 {
+		return
+	}
+
+	// k > 0
+	wPow := w
+	for k := 1; k < m; k++ {
 		t = a[k]
-		tm.Mul(&a[k+m], &wPow)
-		a[k].Add(&a[k], &tm)
+		wPow.ToBigIntRegular(&wPowBigInt)
+		tm.ScalarMultiplication(&a[k+m], &wPowBigInt)
 
-		a[k+m].Sub(&t, &tm)
+		a[k].AddAssign(&tm)
+		a[k+m].Set(&t).SubAssign(&tm)
 
 		wPow.Mul(&wPow, &w)
 	}
@@ -176,6 +1028,31 @@ type Domain struct {
 	GeneratorSqRtInv fr.Element
 	Cardinality      int
 	CardinalityInv   fr.Element
+
+	// Twiddles[l] holds w^0 ... w^(m-1) for m = Cardinality/2, Cardinality/4, ..., the twiddle factors
+	// used at recursion level l of FFT/FFTInverse. TwiddlesInv is the same, built from GeneratorInv.
+	// Precomputing these tables removes one fr.Element.Mul per butterfly (previously wPow.Mul(&wPow, &w)
+	// inside the inner loop) and makes that loop read-only over Twiddles, instead of carrying a
+	// sequentially-updated wPow across iterations.
+	Twiddles    [][]fr.Element
+	TwiddlesInv [][]fr.Element
+
+	// CosetTwiddles[i] = GeneratorSqRt^i and CosetTwiddlesInv[i] = GeneratorSqRtInv^i, i = 0 ... Cardinality-1,
+	// used by CosetFFT/CosetFFTInverse to shift to/from the coset domain without recomputing the powers
+	// of GeneratorSqRt on every call.
+	CosetTwiddles    []fr.Element
+	CosetTwiddlesInv []fr.Element
+
+	// paddedCardinality is set by NewDomainAny when Cardinality is not itself a power of 2: it is the
+	// power-of-2 size of the cyclic convolution used by FFTAny to evaluate the Bluestein (chirp-z)
+	// algorithm. It is 0 for domains returned by NewDomain, whose Cardinality is already a power of 2.
+	paddedCardinality int
+
+	// paddedDomain is the Domain of cardinality paddedCardinality used to run the two power-of-2 FFTs
+	// FFTAny needs; it is built once by NewDomainAny alongside paddedCardinality so that its twiddle
+	// tables are shared across calls to FFTAny instead of being recomputed, and so that FFTAny itself
+	// has no mutable state to race on when the same Domain is used from multiple goroutines.
+	paddedDomain *Domain
 }
 
 // NewDomain returns a subgroup with a power of 2 cardinality
@@ -222,6 +1099,45 @@ func NewDomain(m int) *Domain {
 	subGroup.GeneratorInv.Inverse(&subGroup.Generator)
 	subGroup.CardinalityInv.SetUint64(uint64(x)).Inverse(&subGroup.CardinalityInv)
 
+	// precompute the twiddle factors used by FFT/FFTInverse, one table per recursion level.
+	//
+	// Once Cardinality >= 4, difFFTTableRadix4/ditFFTTableRadix4 consume two levels of recursion at a
+	// time (level, level+2, level+4, ...), so roughly half of these tables - the ones a level-stepped-by-2
+	// radix-4 recursion never lands on - go unread, and of the ones it does land on (sub-FFT size s at that
+	// level) only index 1 and the indices below s/4 are read, rather than their full s/2 length. Building only the levels and
+	// indices radix-4 actually visits would roughly halve this allocation, but which levels/indices those
+	// are depends on where the recursion bottoms out (it isn't simply "every odd level": a Cardinality
+	// that is 2 times a power of 4, for instance, ends in a dead radix-2 tail level too) and isn't worth
+	// getting subtly wrong for a one-time startup cost, so all levels are still built here.
+	nbLevels := bits.TrailingZeros(uint(subGroup.Cardinality))
+	subGroup.Twiddles = make([][]fr.Element, nbLevels)
+	subGroup.TwiddlesInv = make([][]fr.Element, nbLevels)
+	wCur := subGroup.Generator
+	wCurInv := subGroup.GeneratorInv
+	for l := 0; l < nbLevels; l++ {
+		mLevel := subGroup.Cardinality >> (l + 1)
+		subGroup.Twiddles[l] = make([]fr.Element, mLevel)
+		subGroup.TwiddlesInv[l] = make([]fr.Element, mLevel)
+		subGroup.Twiddles[l][0].SetOne()
+		subGroup.TwiddlesInv[l][0].SetOne()
+		for i := 1; i < mLevel; i++ {
+			subGroup.Twiddles[l][i].Mul(&subGroup.Twiddles[l][i-1], &wCur)
+			subGroup.TwiddlesInv[l][i].Mul(&subGroup.TwiddlesInv[l][i-1], &wCurInv)
+		}
+		wCur.Square(&wCur)
+		wCurInv.Square(&wCurInv)
+	}
+
+	// precompute the powers of GeneratorSqRt (resp. GeneratorSqRtInv) used to shift in/out of the coset
+	subGroup.CosetTwiddles = make([]fr.Element, subGroup.Cardinality)
+	subGroup.CosetTwiddlesInv = make([]fr.Element, subGroup.Cardinality)
+	subGroup.CosetTwiddles[0].SetOne()
+	subGroup.CosetTwiddlesInv[0].SetOne()
+	for i := 1; i < subGroup.Cardinality; i++ {
+		subGroup.CosetTwiddles[i].Mul(&subGroup.CosetTwiddles[i-1], &subGroup.GeneratorSqRt)
+		subGroup.CosetTwiddlesInv[i].Mul(&subGroup.CosetTwiddlesInv[i-1], &subGroup.GeneratorSqRtInv)
+	}
+
 	return subGroup
 }
 
@@ -236,5 +1152,100 @@ func nextPowerOfTwo(n uint) uint {
 	return p
 }
 
+// NewDomainAny returns a Domain of cardinality n, which, unlike NewDomain, is not required to be a
+// power of 2. If n is a power of 2, NewDomainAny(n) is equivalent to NewDomain(n) and FFT/FFTInverse
+// can be used directly. Otherwise the returned Domain carries no usable Generator (non-power-of-2
+// subgroups of that size do not exist in general) and a[n] must be transformed with FFTAny instead,
+// which evaluates the length n DFT through a power-of-2 cyclic convolution (the Bluestein/chirp-z
+// algorithm). The size limit of NewDomain still applies, to the padded size nextPowerOfTwo(2n-1): past it
+// NewDomainAny panics with NewDomain's "m is too big" message, and for a non-power-of-2 n that limit is
+// reached at a smaller n than for NewDomain itself.
+//
+// This always goes through Bluestein, even when n factors into small primes with a root of unity of
+// that order available in the field, in which case a mixed-radix Cooley-Tukey split would do less work.
+// Detecting and exploiting such factorizations isn't implemented: it needs a per-prime butterfly family
+// beyond the radix-2/4 ones below, so it's left as a known gap rather than attempted here.
+func NewDomainAny(n int) *Domain {
+	x := nextPowerOfTwo(uint(n))
+	if uint(n) == x {
+		return NewDomain(n)
+	}
+
+	domain := &Domain{Cardinality: n}
+	domain.paddedCardinality = int(nextPowerOfTwo(uint(2*n - 1)))
+	domain.CardinalityInv.SetUint64(uint64(n)).Inverse(&domain.CardinalityInv)
+	domain.paddedDomain = NewDomain(domain.paddedCardinality)
+
+	return domain
+}
+
+// FFTAny computes the discrete Fourier transform of a with respect to w, a len(a)th root of unity in
+// F, and stores the result in a. Unlike FFT, len(a) is not required to be a power of 2: if domain was
+// built by NewDomainAny for a non-power-of-2 cardinality, the transform is computed through the
+// Bluestein (chirp-z) algorithm, which reduces it to a length N = domain.paddedCardinality cyclic
+// convolution (N = nextPowerOfTwo(2n-1)).
+//
+// Bluestein rewrites X_j = sum_k a_k*w^(j*k) using the identity j*k = C(j+k,2) - C(j,2) - C(k,2), where
+// C(m,2) = m*(m-1)/2 and c_k = w^(C(k,2)):
+//
+//	X_j = (1/c_j) * sum_k (a_k/c_k) * w^(C(j+k,2))
+//
+// The right-hand sum is a correlation of (a_k/c_k) against the one-sided chirp table w^(C(m,2)), m = 0
+// ... 2n-2: it is computed as a cyclic convolution of size N by reversing (a_k/c_k) into A and reading
+// the result back with an (n-1) offset. This is the asymmetric form of the chirp trick: the more common
+// presentation folds the table onto a symmetric kernel using the quadratic chirp w^(k^2/2), but that
+// requires a square root of w, which is not guaranteed to exist for an arbitrary len(a)th root of unity.
+//
+// Regardless of domain's cardinality, FFTAny always returns its result in natural order, unlike FFT. The
+// Bluestein branch is natural order inherently (the convolution it reduces to has no bit-reversal step
+// of its own); the power-of-2 passthrough below otherwise follows FFT's usual convention of leaving a
+// DIF result bit-reversed, so that case is normalized with an explicit BitReverse to match. fType still
+// carries its usual meaning for the passthrough's input: DIT expects a already in bit-reversed order,
+// same as a direct call to FFT would.
+func (domain *Domain) FFTAny(a []fr.Element, w fr.Element, fType FFTType) {
+	n := len(a)
+	if domain.paddedCardinality == 0 {
+		FFT(a, w, fType)
+		if fType == DIF {
+			BitReverse(a)
+		}
+		return
+	}
+
+	N := domain.paddedCardinality
+	padded := domain.paddedDomain
+
+	chirp := make([]fr.Element, 2*n-1)
+	chirpInv := make([]fr.Element, n)
+	chirp[0].SetOne()
+	chirpInv[0].SetOne()
+	for k := 1; k < len(chirp); k++ {
+		var e big.Int
+		e.SetInt64(int64(k) * int64(k-1) / 2)
+		chirp[k].Exp(w, &e)
+	}
+	for k := 1; k < n; k++ {
+		chirpInv[k].Inverse(&chirp[k])
+	}
+
+	A := make([]fr.Element, N)
+	B := make([]fr.Element, N)
+	for k := 0; k < n; k++ {
+		A[n-1-k].Mul(&a[k], &chirpInv[k])
+	}
+	copy(B, chirp)
+
+	padded.FFT(A, DIF)
+	padded.FFT(B, DIF)
+	for i := 0; i < N; i++ {
+		A[i].Mul(&A[i], &B[i])
+	}
+	padded.FFTInverse(A, DIT)
+
+	for k := 0; k < n; k++ {
+		a[k].Mul(&A[n-1+k], &chirpInv[k])
+	}
+}
+
 
 `